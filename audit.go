@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// auditEntry records one executed pipeline so users can review, or
+// replay via --replay, what the AI ran.
+type auditEntry struct {
+	ID           string    `json:"id"`
+	Command      string    `json:"command"`
+	ExitCode     int       `json:"exit_code"`
+	DurationMS   int64     `json:"duration_ms"`
+	StdoutSHA256 string    `json:"stdout_sha256"`
+	Sandboxed    bool      `json:"sandboxed"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+func auditLogPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard", "audit.jsonl")
+}
+
+// appendAudit appends one entry to ~/.gemini-shell-wizard/audit.jsonl.
+func appendAudit(entry auditEntry) error {
+	dir := filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(auditLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// newAuditID returns a new identifier for an audit entry, suitable for
+// passing to --replay.
+func newAuditID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+// loadAuditEntry finds the most recent audit entry with the given id.
+func loadAuditEntry(id string) (*auditEntry, error) {
+	data, err := os.ReadFile(auditLogPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == "" {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			continue
+		}
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("no audit entry with id %s", id)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAutoFixIterations is the number of automatic fix-it turns we'll feed
+// back to the model after a suggested command exits non-zero, before
+// giving up and handing control back to the user. Configurable via the
+// [chat] max_autofix_iterations key in config.toml or the
+// GEMINI_SHELL_MAX_AUTOFIX environment variable, set from main.
+var maxAutoFixIterations = 3
+
+// chatSession is a provider-agnostic multi-turn conversation: it drives
+// whatever LLMClient it was built with and persists the turn history to
+// disk so it can be resumed.
+type chatSession struct {
+	client   LLMClient
+	history  []Message
+	histPath string
+}
+
+// historyDir returns ~/.gemini-shell-wizard/history, creating it if needed.
+func historyDir() string {
+	dir := filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard", "history")
+	_ = os.MkdirAll(dir, 0755)
+	return dir
+}
+
+// historyPathForCWD maps the current working directory to a stable history
+// file so re-entering a directory resumes the same conversation.
+func historyPathForCWD() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "default"
+	}
+	sum := sha256.Sum256([]byte(cwd))
+	return filepath.Join(historyDir(), fmt.Sprintf("%x.json", sum))
+}
+
+// loadHistory reads a previously persisted conversation, returning an empty
+// history if none exists yet or the file is unreadable.
+func loadHistory(path string) []Message {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []Message
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// saveHistory persists the full conversation so the next invocation from
+// the same directory can resume it.
+func saveHistory(path string, history []Message) {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0644)
+}
+
+// newChatSession creates a chat, restoring any history saved for this
+// working directory. Used by --chat so a session can be resumed across
+// invocations.
+func newChatSession(client LLMClient) *chatSession {
+	histPath := historyPathForCWD()
+	return &chatSession{client: client, history: loadHistory(histPath), histPath: histPath}
+}
+
+// newEphemeralChatSession creates a chat that neither loads nor persists
+// history. Used by the one-shot (non-chat) path, which is a single
+// question-and-answer exchange and shouldn't grow the per-directory
+// history file that --chat resumes from.
+func newEphemeralChatSession(client LLMClient) *chatSession {
+	return &chatSession{client: client}
+}
+
+// send appends a user turn, streams the model's reply to stdout as it
+// arrives, and returns the full reply text once the stream ends.
+func (s *chatSession) send(ctx context.Context, message string) (string, error) {
+	s.history = append(s.history, Message{Role: "user", Text: message})
+
+	chunks, err := s.client.GenerateStream(ctx, s.history)
+	if err != nil {
+		s.history = s.history[:len(s.history)-1]
+		return "", err
+	}
+
+	fmt.Print("\n\033[1;34mGemini:\033[0m ")
+	var full strings.Builder
+	var streamErr error
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			break
+		}
+		fmt.Print(chunk.Text)
+		full.WriteString(chunk.Text)
+	}
+	fmt.Println()
+	fmt.Println()
+
+	if streamErr != nil {
+		s.history = s.history[:len(s.history)-1]
+		return "", streamErr
+	}
+
+	reply := full.String()
+	s.history = append(s.history, Message{Role: "model", Text: reply})
+	if s.histPath != "" {
+		saveHistory(s.histPath, s.history)
+	}
+	return reply, nil
+}
+
+// runTurn proposes any commands the model suggested and drives the auto
+// error-feedback loop: if a suggested command fails, the failure is fed
+// back into the same chat as the next turn so the model can propose a
+// fix, up to maxAutoFixIterations times.
+func runTurn(ctx context.Context, session *chatSession, reply string) {
+	for iter := 0; ; iter++ {
+		cmds := extractCommands(reply)
+		if len(cmds) == 0 {
+			return
+		}
+
+		results := confirmAndExecute(cmds)
+		failed := firstFailure(results)
+		if failed == nil || iter >= maxAutoFixIterations {
+			return
+		}
+
+		feedback := fmt.Sprintf(
+			"The command `%s` exited with code %d. Stderr tail:\n%s\n\nPlease propose a fix.",
+			failed.command, failed.exitCode, tail(failed.stderr, 40),
+		)
+		next, err := session.send(ctx, feedback)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating fix: %v\n", err)
+			return
+		}
+		reply = next
+	}
+}
+
+// firstFailure returns the first non-zero-exit result, or nil if all
+// commands in the batch succeeded.
+func firstFailure(results []execResult) *execResult {
+	for i := range results {
+		if results[i].exitCode != 0 {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// tail returns at most the last n lines of s.
+func tail(s string, n int) string {
+	lines := splitLines(s)
+	if len(lines) <= n {
+		return s
+	}
+	return joinLines(lines[len(lines)-n:])
+}
+
+// runChatREPL opens an interactive multi-turn session on the user's TTY.
+// It is entered explicitly via --chat, or automatically when
+// gemini-shell-wizard is invoked with no arguments and no piped input.
+func runChatREPL(ctx context.Context, client LLMClient, envInfo string) {
+	session := newChatSession(client)
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		tty = os.Stdin
+	}
+	defer tty.Close()
+	scanner := bufio.NewScanner(tty)
+
+	fmt.Println("\033[1;36mGemini Shell Wizard - chat mode\033[0m (Ctrl+D to exit)")
+	contextBlock := fmt.Sprintf("System Info:\n%s", envInfo)
+
+	first := true
+	for {
+		fmt.Print("\n\033[1;35m>>> \033[0m")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		prompt := line
+		if first {
+			prompt = fmt.Sprintf("%s\n\n%s\n\nUser Question:\n%s", SystemPromptBase, contextBlock, line)
+			first = false
+		}
+
+		reply, err := session.send(ctx, prompt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating content: %v\n", err)
+			continue
+		}
+		runTurn(ctx, session, reply)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(s, "\n"), "\n")
+}
+
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\n")
+}
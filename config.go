@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// config holds user overrides read from config.toml.
+type config struct {
+	// providers maps a ContextProvider's Name() to whether it's enabled,
+	// as set under the [providers] section.
+	providers map[string]bool
+
+	// llmProvider, llmModel, and llmBaseURL come from the [llm] section
+	// and are overridden by the GEMINI_SHELL_PROVIDER, GEMINI_SHELL_MODEL,
+	// and GEMINI_SHELL_BASE_URL environment variables respectively.
+	llmProvider string
+	llmModel    string
+	llmBaseURL  string
+
+	// maxAutoFixIterations comes from the [chat] section's
+	// max_autofix_iterations key, overridden by GEMINI_SHELL_MAX_AUTOFIX.
+	// Zero means unset; callers then keep maxAutoFixIterations' default.
+	maxAutoFixIterations int
+}
+
+func configPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard", "config.toml")
+}
+
+// loadConfig reads config.toml if present. It understands just the small
+// subset of TOML gemini-shell-wizard's settings need: `[section]` headers
+// and `key = value` pairs, no arrays, tables, or multi-line strings.
+func loadConfig() config {
+	cfg := config{providers: map[string]bool{}}
+
+	f, err := os.Open(configPath())
+	if err != nil {
+		return cfg
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch section {
+		case "providers":
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				cfg.providers[key] = enabled
+			}
+		case "llm":
+			value = strings.Trim(value, `"`)
+			switch key {
+			case "provider":
+				cfg.llmProvider = value
+			case "model":
+				cfg.llmModel = value
+			case "base_url":
+				cfg.llmBaseURL = value
+			}
+		case "chat":
+			if key == "max_autofix_iterations" {
+				if n, err := strconv.Atoi(value); err == nil {
+					cfg.maxAutoFixIterations = n
+				}
+			}
+		}
+	}
+	return cfg
+}
+
+// resolveMaxAutoFixIterations returns the configured auto-fix retry count,
+// preferring GEMINI_SHELL_MAX_AUTOFIX over config.toml, or 0 if neither
+// sets it (callers should keep their own default in that case).
+func resolveMaxAutoFixIterations(c config) int {
+	if raw := os.Getenv("GEMINI_SHELL_MAX_AUTOFIX"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			return n
+		}
+	}
+	return c.maxAutoFixIterations
+}
+
+// providerEnabled reports whether a provider should run. Providers default
+// to enabled unless config.toml explicitly disables them.
+func (c config) providerEnabled(name string) bool {
+	if enabled, ok := c.providers[name]; ok {
+		return enabled
+	}
+	return true
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Message is a single turn in a provider-agnostic conversation.
+type Message struct {
+	Role string `json:"role"` // "user" or "model"
+	Text string `json:"text"`
+}
+
+// Chunk is one piece of streamed model output. When the stream ends in
+// error, Err is set and Text is empty.
+type Chunk struct {
+	Text string
+	Err  error
+}
+
+// LLMClient abstracts over model backends so gemini-shell-wizard can talk
+// to Gemini, an OpenAI-compatible endpoint (Groq, OpenRouter, vLLM, LM
+// Studio), Anthropic, or a local Ollama server.
+type LLMClient interface {
+	GenerateStream(ctx context.Context, messages []Message) (<-chan Chunk, error)
+}
+
+// httpTimeout bounds how long we'll wait for a connection and response
+// headers. It is applied via http.Transport.ResponseHeaderTimeout rather
+// than http.Client.Timeout, which would also cut off the body — and with
+// it, any stream that legitimately takes longer than httpTimeout to finish
+// sending chunks.
+const httpTimeout = 30 * time.Second
+
+// newLLMClient builds the backend selected by GEMINI_SHELL_PROVIDER (or
+// config.toml's [llm] section), defaulting to Gemini for backwards
+// compatibility.
+func newLLMClient(ctx context.Context, cfg config) (LLMClient, error) {
+	provider := firstNonEmpty(os.Getenv("GEMINI_SHELL_PROVIDER"), cfg.llmProvider, "gemini")
+	model := firstNonEmpty(os.Getenv("GEMINI_SHELL_MODEL"), cfg.llmModel, defaultModelFor(provider))
+	apiKey := os.Getenv("GEMINI_SHELL_API_KEY")
+	baseURL := firstNonEmpty(os.Getenv("GEMINI_SHELL_BASE_URL"), cfg.llmBaseURL)
+
+	switch provider {
+	case "gemini":
+		if apiKey == "" {
+			return nil, fmt.Errorf("GEMINI_SHELL_API_KEY environment variable not set")
+		}
+		client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: apiKey})
+		if err != nil {
+			return nil, fmt.Errorf("creating gemini client: %w", err)
+		}
+		return &geminiClient{client: client, model: model}, nil
+
+	case "openai", "groq", "openrouter", "vllm", "lmstudio":
+		if baseURL == "" {
+			baseURL = defaultBaseURLFor(provider)
+		}
+		return &openAIClient{baseURL: baseURL, apiKey: apiKey, model: model}, nil
+
+	case "anthropic":
+		if apiKey == "" {
+			return nil, fmt.Errorf("GEMINI_SHELL_API_KEY environment variable not set")
+		}
+		return &anthropicClient{apiKey: apiKey, model: model}, nil
+
+	case "ollama":
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		return &ollamaClient{baseURL: baseURL, model: model}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown GEMINI_SHELL_PROVIDER %q", provider)
+	}
+}
+
+func defaultModelFor(provider string) string {
+	switch provider {
+	case "openai":
+		return "gpt-4o-mini"
+	case "groq":
+		return "llama-3.3-70b-versatile"
+	case "openrouter":
+		return "openrouter/auto"
+	case "vllm", "lmstudio":
+		return "local-model"
+	case "anthropic":
+		return "claude-3-5-sonnet-latest"
+	case "ollama":
+		return "llama3"
+	default:
+		return "gemini-2.0-flash"
+	}
+}
+
+func defaultBaseURLFor(provider string) string {
+	switch provider {
+	case "groq":
+		return "https://api.groq.com/openai/v1"
+	case "openrouter":
+		return "https://openrouter.ai/api/v1"
+	case "lmstudio":
+		return "http://localhost:1234/v1"
+	case "vllm":
+		return "http://localhost:8000/v1"
+	default:
+		return "https://api.openai.com/v1"
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// --- Gemini ---
+
+type geminiClient struct {
+	client *genai.Client
+	model  string
+}
+
+func (c *geminiClient) GenerateStream(ctx context.Context, messages []Message) (<-chan Chunk, error) {
+	contents := toGenaiContents(messages)
+	out := make(chan Chunk)
+
+	go func() {
+		defer close(out)
+		err := withRetry(ctx, func() error {
+			for resp, err := range c.client.Models.GenerateContentStream(ctx, c.model, contents, nil) {
+				if err != nil {
+					return err
+				}
+				if resp != nil {
+					if text := resp.Text(); text != "" {
+						out <- Chunk{Text: text}
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			out <- Chunk{Err: err}
+		}
+	}()
+	return out, nil
+}
+
+func toGenaiContents(messages []Message) []*genai.Content {
+	contents := make([]*genai.Content, len(messages))
+	for i, m := range messages {
+		contents[i] = &genai.Content{
+			Role:  m.Role,
+			Parts: []*genai.Part{{Text: m.Text}},
+		}
+	}
+	return contents
+}
+
+// --- OpenAI-compatible (OpenAI, Groq, OpenRouter, vLLM, LM Studio) ---
+
+type openAIClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+func (c *openAIClient) GenerateStream(ctx context.Context, messages []Message) (<-chan Chunk, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":    c.model,
+		"stream":   true,
+		"messages": toOpenAIMessages(messages),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+
+		resp, err := postWithRetry(ctx, c.baseURL+"/chat/completions", c.apiKey, body)
+		if err != nil {
+			out <- Chunk{Err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok || payload == "[DONE]" {
+				continue
+			}
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+			}
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				continue
+			}
+			for _, choice := range event.Choices {
+				if choice.Delta.Content != "" {
+					out <- Chunk{Text: choice.Delta.Content}
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			out <- Chunk{Err: err}
+		}
+	}()
+	return out, nil
+}
+
+func toOpenAIMessages(messages []Message) []map[string]string {
+	out := make([]map[string]string, len(messages))
+	for i, m := range messages {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		out[i] = map[string]string{"role": role, "content": m.Text}
+	}
+	return out
+}
+
+// --- Anthropic ---
+
+type anthropicClient struct {
+	apiKey string
+	model  string
+}
+
+func (c *anthropicClient) GenerateStream(ctx context.Context, messages []Message) (<-chan Chunk, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":      c.model,
+		"max_tokens": 4096,
+		"stream":     true,
+		"messages":   toAnthropicMessages(messages),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+
+		var resp *http.Response
+		err := withRetry(ctx, func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-api-key", c.apiKey)
+			req.Header.Set("anthropic-version", "2023-06-01")
+			r, err := httpClientForLLM().Do(req)
+			if err != nil {
+				return err
+			}
+			if transient := checkTransientStatus(r); transient != nil {
+				return transient
+			}
+			if r.StatusCode >= 400 {
+				defer r.Body.Close()
+				data, _ := io.ReadAll(r.Body)
+				return fmt.Errorf("anthropic HTTP %d: %s", r.StatusCode, string(data))
+			}
+			resp = r
+			return nil
+		})
+		if err != nil {
+			out <- Chunk{Err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var event struct {
+				Type  string `json:"type"`
+				Delta struct {
+					Text string `json:"text"`
+				} `json:"delta"`
+			}
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				continue
+			}
+			if event.Type == "content_block_delta" && event.Delta.Text != "" {
+				out <- Chunk{Text: event.Delta.Text}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			out <- Chunk{Err: err}
+		}
+	}()
+	return out, nil
+}
+
+func toAnthropicMessages(messages []Message) []map[string]string {
+	out := make([]map[string]string, len(messages))
+	for i, m := range messages {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		out[i] = map[string]string{"role": role, "content": m.Text}
+	}
+	return out
+}
+
+// --- Ollama ---
+
+type ollamaClient struct {
+	baseURL string
+	model   string
+}
+
+func (c *ollamaClient) GenerateStream(ctx context.Context, messages []Message) (<-chan Chunk, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":    c.model,
+		"stream":   true,
+		"messages": toOpenAIMessages(messages), // Ollama uses the same {role, content} shape
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+
+		resp, err := postWithRetry(ctx, c.baseURL+"/api/chat", "", body)
+		if err != nil {
+			out <- Chunk{Err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var event struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+				Done bool `json:"done"`
+			}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				continue
+			}
+			if event.Message.Content != "" {
+				out <- Chunk{Text: event.Message.Content}
+			}
+			if event.Done {
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			out <- Chunk{Err: err}
+		}
+	}()
+	return out, nil
+}
+
+// --- shared HTTP plumbing ---
+
+// llmHTTPClient is shared across providers so the transport's connection
+// pool is reused across calls. It bounds connection setup and response
+// headers via ResponseHeaderTimeout but leaves body reads unbounded, since
+// streamed replies can legitimately run far longer than httpTimeout.
+var llmHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: httpTimeout}).DialContext,
+		TLSHandshakeTimeout:   httpTimeout,
+		ResponseHeaderTimeout: httpTimeout,
+	},
+}
+
+func httpClientForLLM() *http.Client {
+	return llmHTTPClient
+}
+
+// postWithRetry issues a JSON POST, retrying on transient 429/5xx
+// responses, and returns the still-open response body for streaming.
+func postWithRetry(ctx context.Context, url, bearerToken string, body []byte) (*http.Response, error) {
+	var resp *http.Response
+	err := withRetry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if bearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+bearerToken)
+		}
+		r, err := httpClientForLLM().Do(req)
+		if err != nil {
+			return err
+		}
+		if transient := checkTransientStatus(r); transient != nil {
+			return transient
+		}
+		if r.StatusCode >= 400 {
+			defer r.Body.Close()
+			data, _ := io.ReadAll(r.Body)
+			return fmt.Errorf("HTTP %d from %s: %s", r.StatusCode, url, string(data))
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+// transientHTTPError marks a response status as worth retrying.
+type transientHTTPError struct {
+	status int
+}
+
+func (e *transientHTTPError) Error() string {
+	return fmt.Sprintf("transient HTTP status %d", e.status)
+}
+
+func checkTransientStatus(r *http.Response) error {
+	if r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500 {
+		r.Body.Close()
+		return &transientHTTPError{status: r.StatusCode}
+	}
+	return nil
+}
+
+// withRetry retries fn with exponential backoff and jitter when it fails
+// with a transient error, up to maxAttempts total tries.
+func withRetry(ctx context.Context, fn func() error) error {
+	const maxAttempts = 4
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		var transient *transientHTTPError
+		if !errors.As(err, &transient) || attempt == maxAttempts-1 {
+			return err
+		}
+
+		backoff := time.Duration(1<<attempt)*250*time.Millisecond + time.Duration(rand.Intn(250))*time.Millisecond
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -3,16 +3,15 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"regexp"
-	"runtime"
 	"strings"
-
-	"google.golang.org/genai"
+	"time"
 )
 
 // Define the System Prompt
@@ -22,31 +21,44 @@ Rules:
 2. If the user asks for a command, provide it in a markdown code block (e.g., ` + "```bash" + ` ... ` + "```" + `).
 3. Context provided below describes the user's current environment.`
 
-// File to cache environment info
-var cacheFile = filepath.Join(os.Getenv("HOME"), ".gemini-env")
-
 func main() {
-	// 1. Setup API
-	apiKey := os.Getenv("GEMINI_SHELL_API_KEY")
-	if apiKey == "" {
-		fmt.Fprintf(os.Stderr, "Error: GEMINI_SHELL_API_KEY environment variable not set.\n")
-		os.Exit(1)
+	chatFlag := flag.Bool("chat", false, "open a persistent multi-turn chat session")
+	dryRunFlag := flag.Bool("dry-run", false, "print the parsed command pipeline instead of running it")
+	sandboxFlag := flag.Bool("sandbox", false, "run approved commands in an isolated sandbox")
+	netFlag := flag.Bool("net", false, "allow network access inside the sandbox (requires --sandbox)")
+	replayFlag := flag.String("replay", "", "re-run a prior suggestion by its audit log id, without calling the model")
+	flag.Parse()
+	dryRun = *dryRunFlag
+	sandboxEnabled = *sandboxFlag
+	allowNet = *netFlag
+
+	if *replayFlag != "" {
+		entry, err := loadAuditEntry(*replayFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		confirmAndExecute([]string{entry.Command})
+		return
 	}
 
+	// 1. Setup the LLM backend (Gemini by default; see GEMINI_SHELL_PROVIDER)
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: apiKey,
-	})
+	cfg := loadConfig()
+	if n := resolveMaxAutoFixIterations(cfg); n > 0 {
+		maxAutoFixIterations = n
+	}
+	client, err := newLLMClient(ctx, cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating client: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// 2. Get Environment Info (Cached)
-	envInfo := getOrUpdateEnv()
+	// 2. Get Environment Info (each provider caches its own result)
+	envInfo := collectEnvInfo(ctx)
 
 	// 3. Read Arguments (User Question)
-	userPrompt := strings.Join(os.Args[1:], " ")
+	userPrompt := strings.Join(flag.Args(), " ")
 
 	// 4. Read Stdin (Piped Context)
 	var stdinContent string
@@ -57,9 +69,10 @@ func main() {
 		stdinContent = string(bytes)
 	}
 
-	if userPrompt == "" && stdinContent == "" {
-		fmt.Println("Usage: command | >>> [question]")
-		fmt.Println("   or: >>> [question]")
+	// Entering with no question and nothing piped in drops straight into
+	// chat mode instead of printing a usage message.
+	if *chatFlag || (userPrompt == "" && stdinContent == "") {
+		runChatREPL(ctx, client, envInfo)
 		return
 	}
 
@@ -76,71 +89,19 @@ func main() {
 		fullPrompt = fmt.Sprintf("%s\n\n%s\n\nUser Question:\n%s", SystemPromptBase, contextBlock, userPrompt)
 	}
 
-	// 6. Call Gemini
-	resp, err := client.Models.GenerateContent(ctx, "gemini-2.0-flash", genai.Text(fullPrompt), nil)
+	// 6. Call the model through a one-turn chat session so that, if the
+	// suggested command fails, we can feed the failure back as a follow-up
+	// turn and let the model propose a fix.
+	session := newEphemeralChatSession(client)
+	geminiResponse, err := session.send(ctx, fullPrompt)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating content: %v\n", err)
 		os.Exit(1)
 	}
 
-	// 7. Print Output
-	geminiResponse := ""
-	if resp != nil {
-		geminiResponse = resp.Text()
-	}
-	fmt.Print("\n\033[1;34mGemini:\033[0m ")
-	fmt.Println(geminiResponse)
-	fmt.Println()
-
-	// 8. Extract and Propose Execution
-	commands := extractCommands(geminiResponse)
-	if len(commands) > 0 {
-		confirmAndExecute(commands)
-	}
-}
-
-// --- Environment Diagnosis ---
-
-func getOrUpdateEnv() string {
-	// Try reading cache first
-	content, err := os.ReadFile(cacheFile)
-	if err == nil && len(content) > 0 {
-		return string(content)
-	}
-
-	// Generate fresh info
-	info := generateEnvInfo()
-
-	// Save to cache
-	_ = os.WriteFile(cacheFile, []byte(info), 0644)
-	return info
-}
-
-func generateEnvInfo() string {
-	var info strings.Builder
-	info.WriteString(fmt.Sprintf("OS: %s\n", runtime.GOOS))
-	info.WriteString(fmt.Sprintf("Architecture: %s\n", runtime.GOARCH))
-	info.WriteString(fmt.Sprintf("Shell: %s\n", os.Getenv("SHELL")))
-
-	// Try to get Linux Distro details
-	if runtime.GOOS == "linux" {
-		if data, err := os.ReadFile("/etc/os-release"); err == nil {
-			info.WriteString("OS Release Info:\n")
-			// Grab PRETTY_NAME for conciseness
-			re := regexp.MustCompile(`PRETTY_NAME="(.*?)"`)
-			match := re.FindStringSubmatch(string(data))
-			if len(match) > 1 {
-				info.WriteString(match[1] + "\n")
-			} else {
-				info.WriteString(string(data) + "\n") // Fallback
-			}
-		}
-	} else if runtime.GOOS == "darwin" {
-		out, _ := exec.Command("sw_vers").Output()
-		info.WriteString("MacOS Version:\n" + string(out))
-	}
-
-	return info.String()
+	// 7 & 8. The reply was streamed as it arrived; now propose and execute
+	// any suggested commands, automatically retrying with the model on failure.
+	runTurn(ctx, session, geminiResponse)
 }
 
 // --- Command Handling ---
@@ -164,7 +125,24 @@ func extractCommands(text string) []string {
 	return cmds
 }
 
-func confirmAndExecute(cmds []string) {
+// execResult captures what happened when a suggested command was run, so
+// callers can detect failures and feed them back to the model.
+type execResult struct {
+	command  string
+	exitCode int
+	stderr   string
+}
+
+// dryRun, set from the --dry-run flag, makes confirmAndExecute print each
+// parsed pipeline instead of running anything.
+var dryRun bool
+
+// confirmAndExecute parses each suggested code block into pipelines,
+// classifies their risk, and asks for approval one pipeline at a time
+// (y/n/a/q) rather than a single blanket prompt for the whole block.
+// Pipelines classified as dangerous require an explicit "type YES"
+// confirmation instead of a bare "y".
+func confirmAndExecute(cmds []string) []execResult {
 	// Open /dev/tty for user interaction because os.Stdin might be exhausted if piped
 	tty, err := os.Open("/dev/tty")
 	if err != nil {
@@ -174,37 +152,169 @@ func confirmAndExecute(cmds []string) {
 	defer tty.Close()
 	scanner := bufio.NewScanner(tty)
 
-	fmt.Println("\033[1;33mSUGGESTED COMMAND(S):\033[0m")
-	for i, cmd := range cmds {
-		fmt.Printf("[%d] %s\n", i+1, cmd)
-	}
+	var results []execResult
+	approveAll := false
+	for _, block := range cmds {
+		pipelines, err := parsePipelines(block)
+		if err != nil {
+			fmt.Printf("\033[1;31mCould not parse suggested command, skipping:\033[0m %v\n", err)
+			continue
+		}
 
-	fmt.Print("\n\033[1;33mDo you want to execute these commands? [y/N]: \033[0m")
+		for _, pipeline := range pipelines {
+			level, reasons := classifyPipeline(pipeline)
 
-	if scanner.Scan() {
-		input := strings.ToLower(strings.TrimSpace(scanner.Text()))
-		if input == "y" || input == "yes" {
-			for _, cmdStr := range cmds {
-				fmt.Printf("\n\033[1;32mExecuting:\033[0m %s\n", cmdStr)
+			if dryRun {
+				printDryRun(pipeline, reasons)
+				continue
+			}
 
-				// Run the command using the user's shell
-				shell := os.Getenv("SHELL")
-				if shell == "" {
-					shell = "sh"
-				}
+			fmt.Printf("\n\033[1;33m%s\033[0m\n", pipeline.Source)
+			for _, reason := range reasons {
+				fmt.Printf("  \033[1;31m! %s\033[0m\n", reason)
+			}
 
-				cmd := exec.Command(shell, "-c", cmdStr)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				cmd.Stdin = os.Stdin // Allow the command to be interactive (e.g. vim, sudo)
+			approved, all, quit := true, approveAll, false
+			if !approveAll || level == riskDangerous {
+				approved, all, quit = promptApproval(scanner, level)
+			}
+			approveAll = approveAll || all
+			if quit {
+				return results
+			}
+			if !approved {
+				fmt.Println("Skipped.")
+				continue
+			}
 
-				if err := cmd.Run(); err != nil {
-					fmt.Printf("\033[1;31mCommand failed:\033[0m %v\n", err)
-					break // Stop sequence on error
-				}
+			res := runPipeline(pipeline)
+			results = append(results, res)
+			if res.exitCode != 0 {
+				fmt.Printf("\033[1;31mCommand failed:\033[0m exit code %d\n", res.exitCode)
+				return results
 			}
+		}
+	}
+	return results
+}
+
+// promptApproval asks the user whether to run a single pipeline. Dangerous
+// pipelines require typing "YES" verbatim; everything else accepts the
+// usual y/n/a/q.
+func promptApproval(scanner *bufio.Scanner, level riskLevel) (approved, approveAll, quit bool) {
+	for {
+		if level == riskDangerous {
+			fmt.Print("\033[1;31mDANGEROUS - type YES to run, n to skip, q to abort: \033[0m")
 		} else {
-			fmt.Println("Aborted.")
+			fmt.Print("Run this command? [y/n/a/q]: ")
+		}
+		if !scanner.Scan() {
+			return false, false, true
+		}
+		input := strings.TrimSpace(scanner.Text())
+
+		if level == riskDangerous {
+			switch strings.ToLower(input) {
+			case "n", "no":
+				return false, false, false
+			case "q", "quit":
+				return false, false, true
+			}
+			if input == "YES" {
+				return true, false, false
+			}
+			fmt.Println("Must type YES exactly to run a dangerous command.")
+			continue
+		}
+
+		switch strings.ToLower(input) {
+		case "y", "yes":
+			return true, false, false
+		case "n", "no":
+			return false, false, false
+		case "a", "all":
+			return true, true, false
+		case "q", "quit":
+			return false, false, true
+		default:
+			fmt.Println("Please answer y, n, a, or q.")
 		}
 	}
 }
+
+// printDryRun renders a pipeline with each argument shell-quoted instead
+// of executing it.
+func printDryRun(pipeline Pipeline, reasons []string) {
+	parts := make([]string, 0, len(pipeline.Commands))
+	for _, cmd := range pipeline.Commands {
+		quoted := make([]string, len(cmd.Args))
+		for i, a := range cmd.Args {
+			quoted[i] = shellQuote(a)
+		}
+		parts = append(parts, strings.Join(quoted, " "))
+	}
+	fmt.Printf("\033[1;36m[dry-run]\033[0m %s\n", strings.Join(parts, " | "))
+	for _, reason := range reasons {
+		fmt.Printf("  \033[1;31m! %s\033[0m\n", reason)
+	}
+}
+
+// runPipeline executes a single approved pipeline (under --sandbox if
+// enabled), capturing its exit code and a copy of its stderr, and appends
+// an audit log entry recording what ran.
+func runPipeline(pipeline Pipeline) execResult {
+	fmt.Printf("\n\033[1;32mExecuting:\033[0m %s\n", pipeline.Source)
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+
+	prepared := prepareSandboxedCommand(shell, pipeline.Source)
+	cmd := prepared.cmd
+
+	var stdoutBuf, stderrBuf strings.Builder
+	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	cmd.Stdin = os.Stdin // Allow the command to be interactive (e.g. vim, sudo)
+
+	start := time.Now()
+	var restore func()
+	if prepared.beforeStart != nil {
+		restore = prepared.beforeStart()
+	}
+	err := cmd.Start()
+	if restore != nil {
+		restore()
+	}
+	if err == nil {
+		err = cmd.Wait()
+	}
+	duration := time.Since(start)
+
+	exitCode := 0
+	if err != nil {
+		exitCode = exitCodeOf(err)
+	}
+
+	_ = appendAudit(auditEntry{
+		ID:           newAuditID(),
+		Command:      pipeline.Source,
+		ExitCode:     exitCode,
+		DurationMS:   duration.Milliseconds(),
+		StdoutSHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(stdoutBuf.String()))),
+		Sandboxed:    sandboxEnabled,
+		Timestamp:    time.Now(),
+	})
+
+	return execResult{command: pipeline.Source, exitCode: exitCode, stderr: stderrBuf.String()}
+}
+
+// exitCodeOf extracts the process exit code from an *exec.ExitError,
+// falling back to 1 for any other kind of execution error.
+func exitCodeOf(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command is a single executable with its arguments, as parsed from a
+// shell pipeline segment.
+type Command struct {
+	Args []string
+}
+
+// Pipeline is one or more Commands joined by `|`. Source preserves the
+// exact original text of the pipeline so it can still be handed to the
+// user's shell for execution unchanged.
+type Pipeline struct {
+	Commands []Command
+	Source   string
+}
+
+// parsePipelines splits a fenced code block's body into top-level
+// pipelines, honoring single/double quotes, backslash escapes, and
+// $(...)/backtick subshells so separators inside them aren't mistaken for
+// statement boundaries.
+func parsePipelines(script string) ([]Pipeline, error) {
+	segments, err := splitTopLevel(script, []string{"&&", "||", ";", "\n"})
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelines []Pipeline
+	for _, seg := range segments {
+		trimmed := strings.TrimSpace(seg)
+		if trimmed == "" {
+			continue
+		}
+
+		cmdSources, err := splitTopLevel(trimmed, []string{"|"})
+		if err != nil {
+			return nil, err
+		}
+
+		var cmds []Command
+		for _, cs := range cmdSources {
+			args, err := tokenizeWords(cs)
+			if err != nil {
+				return nil, err
+			}
+			if len(args) == 0 {
+				continue
+			}
+			cmds = append(cmds, Command{Args: args})
+		}
+		if len(cmds) == 0 {
+			continue
+		}
+		pipelines = append(pipelines, Pipeline{Commands: cmds, Source: trimmed})
+	}
+	return pipelines, nil
+}
+
+// splitTopLevel splits s on any of seps, but only where the separator
+// appears outside quotes and outside a $(...) or `...` subshell.
+func splitTopLevel(s string, seps []string) ([]string, error) {
+	ordered := append([]string{}, seps...)
+	sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+
+	var result []string
+	var cur strings.Builder
+	depth := 0
+	inSingle, inDouble := false, false
+
+	i, n := 0, len(s)
+	for i < n {
+		c := s[i]
+
+		if !inSingle && c == '\\' && i+1 < n {
+			cur.WriteByte(c)
+			cur.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		if !inDouble && c == '\'' {
+			inSingle = !inSingle
+			cur.WriteByte(c)
+			i++
+			continue
+		}
+		if !inSingle && c == '"' {
+			inDouble = !inDouble
+			cur.WriteByte(c)
+			i++
+			continue
+		}
+		if !inSingle && !inDouble {
+			if c == '$' && i+1 < n && s[i+1] == '(' {
+				depth++
+				cur.WriteString("$(")
+				i += 2
+				continue
+			}
+			if c == '`' {
+				j := nextBacktick(s, i+1)
+				cur.WriteString(s[i:j])
+				i = j
+				continue
+			}
+			if c == '(' && depth > 0 {
+				depth++
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+			if c == ')' && depth > 0 {
+				depth--
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+		}
+
+		if depth == 0 && !inSingle && !inDouble {
+			if matched := matchSep(s[i:], ordered); matched != "" {
+				result = append(result, cur.String())
+				cur.Reset()
+				i += len(matched)
+				continue
+			}
+		}
+
+		cur.WriteByte(c)
+		i++
+	}
+
+	if inSingle || inDouble {
+		return nil, fmt.Errorf("unterminated quote in: %s", s)
+	}
+	result = append(result, cur.String())
+	return result, nil
+}
+
+// tokenizeWords splits a single pipeline segment into words, honoring
+// quotes, backslash escapes, and $(...)/backtick subshells.
+func tokenizeWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	hasCur := false
+	inSingle, inDouble := false, false
+	depth := 0
+
+	flush := func() {
+		if hasCur {
+			words = append(words, cur.String())
+			cur.Reset()
+			hasCur = false
+		}
+	}
+
+	i, n := 0, len(s)
+	for i < n {
+		c := s[i]
+
+		if !inSingle && c == '\\' && i+1 < n {
+			cur.WriteByte(s[i+1])
+			hasCur = true
+			i += 2
+			continue
+		}
+		if !inDouble && c == '\'' {
+			inSingle = !inSingle
+			hasCur = true
+			i++
+			continue
+		}
+		if !inSingle && c == '"' {
+			inDouble = !inDouble
+			hasCur = true
+			i++
+			continue
+		}
+		if !inSingle && !inDouble && depth == 0 && (c == ' ' || c == '\t') {
+			flush()
+			i++
+			continue
+		}
+		if !inSingle && !inDouble && depth == 0 && (c == '>' || c == '<') {
+			// Redirection operators are their own words even when not set
+			// off by whitespace (e.g. "echo x>>/etc/passwd"), so a later
+			// risk check can't be dodged by just dropping the space.
+			flush()
+			if c == '>' && i+1 < n && s[i+1] == '>' {
+				words = append(words, ">>")
+				i += 2
+			} else {
+				words = append(words, string(c))
+				i++
+			}
+			continue
+		}
+		if !inSingle && !inDouble {
+			if c == '$' && i+1 < n && s[i+1] == '(' {
+				depth++
+				cur.WriteString("$(")
+				hasCur = true
+				i += 2
+				continue
+			}
+			if c == '(' && depth > 0 {
+				depth++
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+			if c == ')' && depth > 0 {
+				depth--
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+			if c == '`' {
+				j := nextBacktick(s, i+1)
+				cur.WriteString(s[i:j])
+				hasCur = true
+				i = j
+				continue
+			}
+		}
+
+		cur.WriteByte(c)
+		hasCur = true
+		i++
+	}
+
+	if inSingle || inDouble {
+		return nil, fmt.Errorf("unterminated quote in: %s", s)
+	}
+	flush()
+	return words, nil
+}
+
+// nextBacktick returns the index just past the backtick that closes the
+// subshell starting at from (the character after the opening backtick),
+// or len(s) if it is never closed.
+func nextBacktick(s string, from int) int {
+	j := from
+	for j < len(s) && s[j] != '`' {
+		if s[j] == '\\' && j+1 < len(s) {
+			j += 2
+			continue
+		}
+		j++
+	}
+	if j < len(s) {
+		j++
+	}
+	return j
+}
+
+func matchSep(s string, seps []string) string {
+	for _, sep := range seps {
+		if strings.HasPrefix(s, sep) {
+			return sep
+		}
+	}
+	return ""
+}
+
+// shellQuote renders a as a single shell word, quoting it if necessary so
+// --dry-run output can be pasted back into a shell verbatim.
+func shellQuote(a string) string {
+	if a != "" && !strings.ContainsAny(a, " \t\n'\"$`\\|&;()<>*?[]{}~!") {
+		return a
+	}
+	return "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+}
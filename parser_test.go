@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeWords(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"simple", "echo hello world", []string{"echo", "hello", "world"}},
+		{"single quotes preserve spaces", `echo 'hello world'`, []string{"echo", "hello world"}},
+		{"double quotes preserve spaces", `echo "hello world"`, []string{"echo", "hello world"}},
+		{"backslash escape", `echo hello\ world`, []string{"echo", "hello world"}},
+		{"combined flags", "rm -rf /tmp/x", []string{"rm", "-rf", "/tmp/x"}},
+		{"command substitution kept intact", "echo $(date +%s)", []string{"echo", "$(date +%s)"}},
+		{"backtick substitution kept intact", "echo `date`", []string{"echo", "`date`"}},
+		{"redirect with spaces", "echo foo > /etc/shadow", []string{"echo", "foo", ">", "/etc/shadow"}},
+		{"redirect with no space before target", "echo foo >/etc/shadow", []string{"echo", "foo", ">", "/etc/shadow"}},
+		{"append with no space on either side", "echo x>>/etc/passwd", []string{"echo", "x", ">>", "/etc/passwd"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tokenizeWords(tc.in)
+			if err != nil {
+				t.Fatalf("tokenizeWords(%q) returned error: %v", tc.in, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("tokenizeWords(%q) = %#v, want %#v", tc.in, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := tokenizeWords(`echo "unterminated`); err == nil {
+		t.Error("tokenizeWords with unterminated quote: want error, got nil")
+	}
+}
+
+func TestParsePipelines(t *testing.T) {
+	cases := []struct {
+		name      string
+		in        string
+		wantLens  []int // number of commands in each pipeline
+		wantCount int
+	}{
+		{"single command", "ls -la", []int{1}, 1},
+		{"pipeline", "cat file | grep foo | wc -l", []int{3}, 1},
+		{"chained statements", "mkdir foo && cd foo", []int{1, 1}, 2},
+		{"semicolon separated", "echo a; echo b", []int{1, 1}, 2},
+		{"newline separated", "echo a\necho b", []int{1, 1}, 2},
+		{"pipe inside quotes is not a separator", `echo "a | b"`, []int{1}, 1},
+		{"pipe inside subshell is not a separator", "echo $(cat a | grep b)", []int{1}, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pipelines, err := parsePipelines(tc.in)
+			if err != nil {
+				t.Fatalf("parsePipelines(%q) returned error: %v", tc.in, err)
+			}
+			if len(pipelines) != tc.wantCount {
+				t.Fatalf("parsePipelines(%q) = %d pipelines, want %d", tc.in, len(pipelines), tc.wantCount)
+			}
+			for i, p := range pipelines {
+				if len(p.Commands) != tc.wantLens[i] {
+					t.Errorf("pipeline %d has %d commands, want %d", i, len(p.Commands), tc.wantLens[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContextProvider supplies one block of environment context (OS info,
+// shell, toolchains, ...) to include in the prompt sent to the model.
+type ContextProvider interface {
+	// Name identifies the provider. It doubles as the cache filename and
+	// the config.toml key used to enable/disable it.
+	Name() string
+	// Collect gathers the provider's context block, respecting ctx's
+	// deadline/cancellation.
+	Collect(ctx context.Context) (string, error)
+	// TTL is how long a successful result may be served from cache before
+	// Collect is called again.
+	TTL() time.Duration
+}
+
+// providerTimeout bounds how long the registry waits for any single
+// provider before giving up on it for this run.
+const providerTimeout = 3 * time.Second
+
+// builtinProviders lists every provider shipped with gemini-shell-wizard.
+// Each can be disabled via config.toml.
+func builtinProviders() []ContextProvider {
+	return []ContextProvider{
+		osReleaseProvider{},
+		shellProvider{},
+		packageManagerProvider{},
+		containerProvider{},
+		gitRepoProvider{},
+		toolchainProvider{},
+		cloudCLIProvider{},
+		resourceProvider{},
+	}
+}
+
+// collectEnvInfo runs every enabled provider (built-in, plus any dropped
+// into providers.d/) in parallel, honoring per-provider cache TTLs, and
+// joins their output into the context block sent to the model.
+func collectEnvInfo(ctx context.Context) string {
+	cfg := loadConfig()
+
+	var providers []ContextProvider
+	for _, p := range append(builtinProviders(), externalProviders()...) {
+		if cfg.providerEnabled(p.Name()) {
+			providers = append(providers, p)
+		}
+	}
+
+	texts := make([]string, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p ContextProvider) {
+			defer wg.Done()
+			texts[i] = collectWithCache(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var out strings.Builder
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		out.WriteString(text)
+		if !strings.HasSuffix(text, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// collectWithCache serves a provider's cached result if it's still within
+// TTL, otherwise calls Collect (bounded by providerTimeout) and refreshes
+// the cache.
+func collectWithCache(ctx context.Context, p ContextProvider) string {
+	cachePath := cacheFileFor(p.Name())
+	if text, ok := readCache(cachePath, p.TTL()); ok {
+		return text
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+
+	text, err := p.Collect(cctx)
+	if err != nil {
+		return ""
+	}
+	writeCache(cachePath, text)
+	return text
+}
+
+func cacheDir() string {
+	dir := filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard", "cache")
+	_ = os.MkdirAll(dir, 0755)
+	return dir
+}
+
+func cacheFileFor(name string) string {
+	return filepath.Join(cacheDir(), name+".txt")
+}
+
+func readCache(path string, ttl time.Duration) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if time.Since(info.ModTime()) > ttl {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func writeCache(path, text string) {
+	_ = os.WriteFile(path, []byte(text), 0644)
+}
+
+// --- Built-in providers ---
+
+type osReleaseProvider struct{}
+
+func (osReleaseProvider) Name() string       { return "os" }
+func (osReleaseProvider) TTL() time.Duration { return 24 * time.Hour }
+func (osReleaseProvider) Collect(ctx context.Context) (string, error) {
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("OS: %s\n", runtime.GOOS))
+	info.WriteString(fmt.Sprintf("Architecture: %s\n", runtime.GOARCH))
+
+	if runtime.GOOS == "linux" {
+		if data, err := os.ReadFile("/etc/os-release"); err == nil {
+			re := regexp.MustCompile(`PRETTY_NAME="(.*?)"`)
+			if match := re.FindStringSubmatch(string(data)); len(match) > 1 {
+				info.WriteString("OS Release Info:\n" + match[1] + "\n")
+			} else {
+				info.WriteString("OS Release Info:\n" + string(data) + "\n")
+			}
+		}
+	} else if runtime.GOOS == "darwin" {
+		out, _ := exec.CommandContext(ctx, "sw_vers").Output()
+		info.WriteString("MacOS Version:\n" + string(out))
+	}
+	return info.String(), nil
+}
+
+type shellProvider struct{}
+
+func (shellProvider) Name() string       { return "shell" }
+func (shellProvider) TTL() time.Duration { return 24 * time.Hour }
+func (shellProvider) Collect(ctx context.Context) (string, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return "", nil
+	}
+	version := ""
+	if out, err := exec.CommandContext(ctx, shell, "--version").Output(); err == nil {
+		version = strings.SplitN(string(out), "\n", 2)[0]
+	}
+	if version == "" {
+		return fmt.Sprintf("Shell: %s\n", shell), nil
+	}
+	return fmt.Sprintf("Shell: %s (%s)\n", shell, version), nil
+}
+
+type packageManagerProvider struct{}
+
+func (packageManagerProvider) Name() string       { return "package_managers" }
+func (packageManagerProvider) TTL() time.Duration { return 24 * time.Hour }
+func (packageManagerProvider) Collect(ctx context.Context) (string, error) {
+	candidates := []string{"apt", "dnf", "pacman", "brew", "apk", "pkg"}
+	var found []string
+	for _, name := range candidates {
+		if _, err := exec.LookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("Package Managers: %s\n", strings.Join(found, ", ")), nil
+}
+
+type containerProvider struct{}
+
+func (containerProvider) Name() string       { return "containers" }
+func (containerProvider) TTL() time.Duration { return time.Hour }
+func (containerProvider) Collect(ctx context.Context) (string, error) {
+	var indicators []string
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		indicators = append(indicators, "docker (/.dockerenv)")
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		if strings.Contains(string(data), "docker") || strings.Contains(string(data), "containerd") || strings.Contains(string(data), "kubepods") {
+			indicators = append(indicators, "container cgroup")
+		}
+	}
+	if out, err := exec.CommandContext(ctx, "systemd-detect-virt").Output(); err == nil {
+		if virt := strings.TrimSpace(string(out)); virt != "" && virt != "none" {
+			indicators = append(indicators, "virt: "+virt)
+		}
+	}
+	if len(indicators) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("Virtualization: %s\n", strings.Join(indicators, ", ")), nil
+}
+
+type gitRepoProvider struct{}
+
+func (gitRepoProvider) Name() string       { return "git" }
+func (gitRepoProvider) TTL() time.Duration { return 5 * time.Second }
+func (gitRepoProvider) Collect(ctx context.Context) (string, error) {
+	if err := exec.CommandContext(ctx, "git", "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		return "", nil
+	}
+
+	branchOut, _ := exec.CommandContext(ctx, "git", "branch", "--show-current").Output()
+	branch := strings.TrimSpace(string(branchOut))
+
+	remoteOut, _ := exec.CommandContext(ctx, "git", "remote", "get-url", "origin").Output()
+	remote := strings.TrimSpace(string(remoteOut))
+
+	dirty := "clean"
+	if statusOut, _ := exec.CommandContext(ctx, "git", "status", "--porcelain").Output(); len(strings.TrimSpace(string(statusOut))) > 0 {
+		dirty = "dirty"
+	}
+
+	var info strings.Builder
+	info.WriteString("Git Repo:\n")
+	if branch != "" {
+		info.WriteString(fmt.Sprintf("  Branch: %s\n", branch))
+	}
+	if remote != "" {
+		info.WriteString(fmt.Sprintf("  Remote: %s\n", remote))
+	}
+	info.WriteString(fmt.Sprintf("  State: %s\n", dirty))
+	return info.String(), nil
+}
+
+type toolchainProvider struct{}
+
+func (toolchainProvider) Name() string       { return "toolchains" }
+func (toolchainProvider) TTL() time.Duration { return time.Hour }
+func (toolchainProvider) Collect(ctx context.Context) (string, error) {
+	type tool struct {
+		bin  string
+		args []string
+	}
+	tools := []tool{
+		{"python3", []string{"--version"}},
+		{"node", []string{"--version"}},
+		{"go", []string{"version"}},
+	}
+
+	var lines []string
+	for _, t := range tools {
+		if _, err := exec.LookPath(t.bin); err != nil {
+			continue
+		}
+		out, err := exec.CommandContext(ctx, t.bin, t.args...).CombinedOutput()
+		if err != nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", t.bin, strings.TrimSpace(string(out))))
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return "Toolchains:\n  " + strings.Join(lines, "\n  ") + "\n", nil
+}
+
+type cloudCLIProvider struct{}
+
+func (cloudCLIProvider) Name() string       { return "cloud_cli" }
+func (cloudCLIProvider) TTL() time.Duration { return time.Hour }
+func (cloudCLIProvider) Collect(ctx context.Context) (string, error) {
+	var lines []string
+	if _, err := exec.LookPath("aws"); err == nil {
+		profile := os.Getenv("AWS_PROFILE")
+		if profile == "" {
+			profile = "default"
+		}
+		lines = append(lines, fmt.Sprintf("aws (profile: %s)", profile))
+	}
+	if _, err := exec.LookPath("gcloud"); err == nil {
+		out, _ := exec.CommandContext(ctx, "gcloud", "config", "get-value", "project").Output()
+		lines = append(lines, fmt.Sprintf("gcloud (project: %s)", strings.TrimSpace(string(out))))
+	}
+	if _, err := exec.LookPath("az"); err == nil {
+		lines = append(lines, "az")
+	}
+	if _, err := exec.LookPath("kubectl"); err == nil {
+		out, _ := exec.CommandContext(ctx, "kubectl", "config", "current-context").Output()
+		lines = append(lines, fmt.Sprintf("kubectl (context: %s)", strings.TrimSpace(string(out))))
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return "Cloud CLIs:\n  " + strings.Join(lines, "\n  ") + "\n", nil
+}
+
+type resourceProvider struct{}
+
+func (resourceProvider) Name() string       { return "resources" }
+func (resourceProvider) TTL() time.Duration { return 30 * time.Second }
+func (resourceProvider) Collect(ctx context.Context) (string, error) {
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("CPUs: %d\n", runtime.NumCPU()))
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		re := regexp.MustCompile(`MemTotal:\s+(\d+) kB`)
+		if match := re.FindStringSubmatch(string(data)); len(match) > 1 {
+			info.WriteString(fmt.Sprintf("Memory: %s kB\n", match[1]))
+		}
+	}
+	return info.String(), nil
+}
+
+// --- providers.d/ out-of-tree providers ---
+
+// execProvider wraps an executable dropped into providers.d/: its stdout
+// becomes the provider's context block.
+type execProvider struct {
+	path string
+}
+
+func (p execProvider) Name() string    { return filepath.Base(p.path) }
+func (execProvider) TTL() time.Duration { return 5 * time.Minute }
+func (p execProvider) Collect(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, p.path).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// externalProviders discovers executables under
+// ~/.gemini-shell-wizard/providers.d/ and wraps each as a ContextProvider.
+func externalProviders() []ContextProvider {
+	dir := filepath.Join(os.Getenv("HOME"), ".gemini-shell-wizard", "providers.d")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var providers []ContextProvider
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+		providers = append(providers, execProvider{path: filepath.Join(dir, e.Name())})
+	}
+	return providers
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// riskLevel buckets a pipeline by how much damage it could do, which
+// controls the strength of confirmation confirmAndExecute demands before
+// running it.
+type riskLevel int
+
+const (
+	riskSafe riskLevel = iota
+	riskCaution
+	riskDangerous
+)
+
+// classifyPipeline inspects a parsed Pipeline for known-dangerous shell
+// patterns and returns the highest risk level found along with a
+// human-readable reason for each hit.
+func classifyPipeline(p Pipeline) (riskLevel, []string) {
+	level := riskSafe
+	var reasons []string
+	raise := func(l riskLevel, reason string) {
+		if l > level {
+			level = l
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if looksLikeForkBomb(p.Source) {
+		raise(riskDangerous, "looks like a fork bomb")
+	}
+
+	for idx, cmd := range p.Commands {
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		args := cmd.Args
+		name := filepath.Base(args[0])
+		elevated := name == "sudo" || name == "doas"
+		if elevated {
+			raise(riskCaution, "escalates privileges via "+name)
+			if rest := stripPrivilegeEscalation(args); len(rest) > 0 {
+				args = rest
+				name = filepath.Base(args[0])
+			}
+		}
+
+		switch {
+		case name == "rm" && hasRecursiveAndForce(args) && anyArgIsRootPath(args):
+			raise(riskDangerous, "rm -rf targets / or a top-level directory")
+
+		case name == "dd":
+			if target := ddOutputDevice(args); target != "" {
+				raise(riskDangerous, fmt.Sprintf("dd writes directly to device %s", target))
+			}
+
+		case strings.HasPrefix(name, "mkfs"):
+			raise(riskDangerous, fmt.Sprintf("formats a filesystem (%s)", name))
+
+		case name == "chmod" && hasFlag(args, "-R", "--recursive") && containsArg(args, "777") && anyArgIsRootPath(args):
+			raise(riskDangerous, "recursive chmod 777 on / or a top-level directory")
+
+		case name == "find" && containsArg(args, "-delete") && !hasBoundedSearchRoot(args):
+			raise(riskDangerous, "find -delete with no narrow starting path")
+
+		case name == "curl" || name == "wget":
+			if pipesIntoShell(p.Commands, idx) {
+				raise(riskDangerous, "pipes a remote download directly into a shell")
+			}
+		}
+
+		if target := redirectTarget(cmd.Args); strings.HasPrefix(target, "/etc/") {
+			raise(riskDangerous, fmt.Sprintf("writes under /etc (%s)", target))
+		}
+	}
+
+	return level, reasons
+}
+
+func looksLikeForkBomb(source string) bool {
+	collapsed := strings.ReplaceAll(source, " ", "")
+	return strings.Contains(collapsed, ":(){:|:&};:") || strings.Contains(collapsed, ":(){:|:&};")
+}
+
+// hasRecursiveAndForce reports whether args contain both a "recursive" and
+// a "force" flag, in either combined (-rf) or separate (-r -f) form.
+func hasRecursiveAndForce(args []string) bool {
+	r, f := false, false
+	for _, a := range args {
+		switch a {
+		case "--recursive":
+			r = true
+		case "--force":
+			f = true
+		}
+		if strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") {
+			if strings.ContainsRune(a, 'r') {
+				r = true
+			}
+			if strings.ContainsRune(a, 'f') {
+				f = true
+			}
+		}
+	}
+	return r && f
+}
+
+func hasFlag(args []string, names ...string) bool {
+	for _, a := range args {
+		for _, name := range names {
+			if a == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsArg(args []string, value string) bool {
+	for _, a := range args {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// anyArgIsRootPath reports whether any positional argument is "/", a glob
+// directly under it ("/*"), or a single top-level directory ("/etc").
+func anyArgIsRootPath(args []string) bool {
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		if a == "/" || a == "/*" {
+			return true
+		}
+		if strings.HasPrefix(a, "/") && strings.Count(a, "/") == 1 && len(a) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPrivilegeEscalation drops a leading sudo/doas and its flags (e.g.
+// "-u root", "-n") so the real command that follows can be classified on
+// its own merits instead of masquerading as the wrapper.
+func stripPrivilegeEscalation(args []string) []string {
+	i := 1
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		switch args[i] {
+		case "-u", "-g", "-C", "-D", "-p", "-r", "-t", "-U", "-T", "--user", "--group":
+			i++ // the flag's value is a separate word
+		}
+		i++
+	}
+	if i >= len(args) {
+		return nil
+	}
+	return args[i:]
+}
+
+func ddOutputDevice(args []string) string {
+	for _, a := range args {
+		if strings.HasPrefix(a, "of=/dev/") {
+			return strings.TrimPrefix(a, "of=")
+		}
+	}
+	return ""
+}
+
+// hasBoundedSearchRoot reports whether a find invocation's starting path
+// looks narrower than the whole filesystem. args[0] is the "find" command
+// itself, so the search begins at args[1].
+func hasBoundedSearchRoot(args []string) bool {
+	for _, a := range args[1:] {
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		if a == "/" || a == "." || a == "" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// pipesIntoShell reports whether any later stage of the pipeline runs a
+// shell, which combined with an earlier curl/wget means "download and run".
+func pipesIntoShell(cmds []Command, from int) bool {
+	for _, cmd := range cmds[from+1:] {
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		switch filepath.Base(cmd.Args[0]) {
+		case "sh", "bash", "zsh", "source":
+			return true
+		}
+	}
+	return false
+}
+
+// redirectTarget returns the path a command redirects output to via `>`
+// or `>>`, if any.
+func redirectTarget(args []string) string {
+	for i, a := range args {
+		if (a == ">" || a == ">>") && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
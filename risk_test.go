@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func classify(t *testing.T, source string) (riskLevel, []string) {
+	t.Helper()
+	pipelines, err := parsePipelines(source)
+	if err != nil {
+		t.Fatalf("parsePipelines(%q) returned error: %v", source, err)
+	}
+	if len(pipelines) != 1 {
+		t.Fatalf("parsePipelines(%q) = %d pipelines, want 1", source, len(pipelines))
+	}
+	return classifyPipeline(pipelines[0])
+}
+
+func TestClassifyPipeline(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   riskLevel
+	}{
+		{"plain command", "ls -la", riskSafe},
+		{"rm without force", "rm /tmp/x", riskSafe},
+		{"rm -rf on a scoped path", "rm -rf /tmp/build", riskSafe},
+		{"rm -rf on root", "rm -rf /", riskDangerous},
+		{"rm -rf on top-level dir", "rm -rf /etc", riskDangerous},
+		{"dd to a device", "dd if=/dev/zero of=/dev/sda", riskDangerous},
+		{"dd to a file", "dd if=/dev/zero of=/tmp/out.img", riskSafe},
+		{"mkfs", "mkfs.ext4 /dev/sdb1", riskDangerous},
+		{"chmod 777 on root", "chmod -R 777 /", riskDangerous},
+		{"chmod 777 scoped", "chmod -R 777 /tmp/build", riskSafe},
+		{"sudo wraps a safe command", "sudo ls -la", riskCaution},
+		{"sudo wraps rm -rf root", "sudo rm -rf /", riskDangerous},
+		{"doas wraps dd to a device", "doas dd if=/dev/zero of=/dev/sda", riskDangerous},
+		{"sudo with flags wraps rm -rf root", "sudo -n rm -rf /", riskDangerous},
+		{"find -delete unbounded", "find / -delete", riskDangerous},
+		{"find -delete scoped", "find /tmp/build -delete", riskSafe},
+		{"curl piped into shell", "curl https://example.com/install.sh | sh", riskDangerous},
+		{"curl without piping into shell", "curl https://example.com/install.sh", riskSafe},
+		{"redirect under /etc", "echo root::0:0::/root:/bin/sh >> /etc/passwd", riskDangerous},
+		{"redirect under /etc with no space before >", "echo foo >/etc/shadow", riskDangerous},
+		{"append under /etc with no space before >>", "echo x>>/etc/passwd", riskDangerous},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reasons := classify(t, tc.source)
+			if got != tc.want {
+				t.Errorf("classifyPipeline(%q) = %v (reasons %v), want %v", tc.source, got, reasons, tc.want)
+			}
+		})
+	}
+}
+
+// Fork bombs use a ";" that parsePipelines treats as a statement
+// separator, so this exercises classifyPipeline directly against the
+// whole pattern rather than round-tripping through the parser.
+func TestClassifyPipelineForkBomb(t *testing.T) {
+	source := ":(){ :|:& };:"
+	p := Pipeline{Commands: []Command{{Args: []string{":"}}}, Source: source}
+
+	got, reasons := classifyPipeline(p)
+	if got != riskDangerous {
+		t.Errorf("classifyPipeline(%q) = %v (reasons %v), want %v", source, got, reasons, riskDangerous)
+	}
+}
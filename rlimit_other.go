@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+// applyChildRlimits is a no-op on platforms other than Linux and macOS:
+// no rlimits are applied to the child there.
+func applyChildRlimits() func() {
+	return func() {}
+}
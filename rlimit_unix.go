@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package main
+
+import "syscall"
+
+// sandboxRlimits bounds a sandboxed child when no bwrap/firejail/
+// sandbox-exec is available: 60 CPU-seconds, 1GiB of address space, and
+// 256 open files.
+var sandboxRlimits = []struct {
+	resource int
+	limit    uint64
+}{
+	{syscall.RLIMIT_CPU, 60},
+	{syscall.RLIMIT_AS, 1 << 30},
+	{syscall.RLIMIT_NOFILE, 256},
+}
+
+// applyChildRlimits temporarily lowers this process's own rlimits so that
+// the child exec.Cmd.Start() is about to fork inherits the restricted
+// values, then returns a restore func that puts this process's limits
+// back. Go's exec.Cmd has no portable way to set rlimits on the child
+// directly, but rlimits survive fork(2) until the child calls exec, so
+// tightening them here for the brief window around Start achieves the
+// same effect without touching the parent's long-term limits.
+func applyChildRlimits() func() {
+	var saved []syscall.Rlimit
+	for _, r := range sandboxRlimits {
+		var old syscall.Rlimit
+		if err := syscall.Getrlimit(r.resource, &old); err != nil {
+			saved = append(saved, syscall.Rlimit{})
+			continue
+		}
+		saved = append(saved, old)
+
+		_ = syscall.Setrlimit(r.resource, &syscall.Rlimit{Cur: r.limit, Max: old.Max})
+	}
+
+	return func() {
+		for i, r := range sandboxRlimits {
+			_ = syscall.Setrlimit(r.resource, &saved[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// sandboxEnabled, set from --sandbox, makes prepareSandboxedCommand
+// isolate execution using the best tool available on the host.
+var sandboxEnabled bool
+
+// allowNet, set from --net, permits network access inside the sandbox.
+// It's ignored when sandboxEnabled is false.
+var allowNet bool
+
+// preparedCmd is a command ready to run, plus an optional hook the caller
+// must invoke immediately around cmd.Start() when no sandboxing tool was
+// available and we instead fall back to temporarily lowering this
+// process's own resource limits (which the forked child inherits).
+type preparedCmd struct {
+	cmd         *exec.Cmd
+	beforeStart func() (restore func())
+}
+
+// prepareSandboxedCommand builds the command that will run a pipeline's
+// source under the user's shell, honoring --sandbox:
+//   - Linux: bwrap, falling back to firejail, both bind-mounting only the
+//     CWD read-write and $HOME read-only, with networking disabled unless
+//     --net was given.
+//   - macOS: sandbox-exec with a generated profile granting the same
+//     read/write scope.
+//   - Anywhere else, or when no sandboxing tool is installed: a plain
+//     process with CPU time, address space, and open-file rlimits applied
+//     on Linux and macOS; on other platforms it runs with no limits.
+func prepareSandboxedCommand(shell, source string) preparedCmd {
+	if !sandboxEnabled {
+		return preparedCmd{cmd: exec.Command(shell, "-c", source)}
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if path, err := exec.LookPath("bwrap"); err == nil {
+			return preparedCmd{cmd: bwrapCommand(path, shell, source)}
+		}
+		if path, err := exec.LookPath("firejail"); err == nil {
+			return preparedCmd{cmd: firejailCommand(path, shell, source)}
+		}
+	case "darwin":
+		if path, err := exec.LookPath("sandbox-exec"); err == nil {
+			return preparedCmd{cmd: sandboxExecCommand(path, shell, source)}
+		}
+	}
+
+	return preparedCmd{cmd: exec.Command(shell, "-c", source), beforeStart: applyChildRlimits}
+}
+
+func bwrapCommand(bwrapPath, shell, source string) *exec.Cmd {
+	home := os.Getenv("HOME")
+	cwd, _ := os.Getwd()
+
+	args := []string{
+		"--die-with-parent",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--ro-bind", "/usr", "/usr",
+		"--ro-bind", "/bin", "/bin",
+		"--ro-bind", "/lib", "/lib",
+		"--ro-bind", "/etc", "/etc",
+		"--ro-bind", home, home,
+		"--bind", cwd, cwd,
+		"--chdir", cwd,
+	}
+	if _, err := os.Stat("/lib64"); err == nil {
+		args = append(args, "--ro-bind", "/lib64", "/lib64")
+	}
+	if !allowNet {
+		args = append(args, "--unshare-net")
+	}
+	args = append(args, shell, "-c", source)
+	return exec.Command(bwrapPath, args...)
+}
+
+func firejailCommand(firejailPath, shell, source string) *exec.Cmd {
+	home := os.Getenv("HOME")
+	cwd, _ := os.Getwd()
+
+	args := []string{"--quiet", "--whitelist=" + cwd, "--read-only=" + home}
+	if !allowNet {
+		args = append(args, "--net=none")
+	}
+	args = append(args, shell, "-c", source)
+	return exec.Command(firejailPath, args...)
+}
+
+func sandboxExecCommand(sandboxExecPath, shell, source string) *exec.Cmd {
+	return exec.Command(sandboxExecPath, "-p", sandboxExecProfile(), shell, "-c", source)
+}
+
+// sandboxExecProfile generates a minimal Seatbelt profile allowing
+// read/write under the CWD, read-only under $HOME, and network access
+// only when --net was given.
+func sandboxExecProfile() string {
+	cwd, _ := os.Getwd()
+	home := os.Getenv("HOME")
+
+	net := "(deny network*)"
+	if allowNet {
+		net = "(allow network*)"
+	}
+
+	return fmt.Sprintf(`(version 1)
+(allow default)
+(deny file-write* (subpath "/"))
+(allow file-write* (subpath %q))
+(allow file-read* (subpath %q))
+%s
+`, cwd, home, net)
+}